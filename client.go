@@ -13,24 +13,77 @@ import (
 	"github.com/google/uuid"
 )
 
+// ClientMode selects what kind of traffic a Client forwards to the local service.
+type ClientMode int
+
+const (
+	// ClientModeTCP forwards TCP streams (the default).
+	ClientModeTCP ClientMode = iota
+	// ClientModeUDP tunnels UDP datagrams.
+	ClientModeUDP
+)
+
 // Client maintains a control connection to a bore server and proxies incoming connections.
 type Client struct {
+	to        string
+	localHost string
+	localPort uint16
+	auth      *Authenticator
+	mode      ClientMode
+	transport Transport
+	metrics   *Metrics
+
+	// connMu guards conn, muxSession and remotePort, which Run/reconnect
+	// replace on every reconnect while other goroutines (RemotePort, Close,
+	// the per-connection handlers spawned from listenOnce) read them live.
+	connMu     sync.RWMutex
 	conn       *Delimited
-	to         string
-	localHost  string
-	localPort  uint16
+	muxSession *muxSession
 	remotePort uint16
-	auth       *Authenticator
 
 	connected     atomic.Bool
 	activeProxies atomic.Int64
 	lastHeartbeat atomic.Int64
 	closeOnce     sync.Once
+
+	// OnConnect, if set, is called by Run after the control connection is
+	// established or re-established.
+	OnConnect func()
+	// OnDisconnect, if set, is called by Run when the control connection
+	// fails, before it attempts to reconnect.
+	OnDisconnect func(err error)
+	// OnRemotePortChanged, if set, is called by Run when a reconnect is
+	// assigned a different remote port than before.
+	OnRemotePortChanged func(old, new uint16)
+
+	// PreserveSourceAddr, when true, prepends a PROXY protocol v2 header to
+	// each proxied TCP connection so the local service can recover the
+	// original client address instead of seeing the tunnel's. Requires the
+	// server to report the client address on ServerConnection messages, and
+	// the local service to understand PROXY v2.
+	PreserveSourceAddr bool
 }
 
 // NewClient connects to the remote server and performs the initial handshake.
 func NewClient(localHost string, localPort uint16, to string, desiredPort uint16, secret string) (*Client, error) {
-	conn, err := connectWithTimeout(to, ControlPort)
+	return newClient(localHost, localPort, to, desiredPort, secret, ClientModeTCP, TCPTransport{})
+}
+
+// NewClientWithMode connects to the remote server and performs the initial handshake,
+// negotiating the given forwarding mode.
+func NewClientWithMode(localHost string, localPort uint16, to string, desiredPort uint16, secret string, mode ClientMode) (*Client, error) {
+	return newClient(localHost, localPort, to, desiredPort, secret, mode, TCPTransport{})
+}
+
+// NewClientWithTransport connects to the remote server over the given Transport
+// and performs the initial handshake. Use this to run the control channel and
+// proxied connections over a carrier other than raw TCP, such as WebSocketTransport.
+func NewClientWithTransport(localHost string, localPort uint16, to string, desiredPort uint16, secret string, transport Transport) (*Client, error) {
+	return newClient(localHost, localPort, to, desiredPort, secret, ClientModeTCP, transport)
+}
+
+func newClient(localHost string, localPort uint16, to string, desiredPort uint16, secret string, mode ClientMode, transport Transport) (*Client, error) {
+	conn, err := dialServer(transport, to, ControlPort)
 	if err != nil {
 		return nil, err
 	}
@@ -39,58 +92,146 @@ func NewClient(localHost string, localPort uint16, to string, desiredPort uint16
 	var auth *Authenticator
 	if secret != "" {
 		auth = NewAuthenticator(secret)
+	}
+	remotePort, err := performHandshake(framed, desiredPort, auth, mode)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client := &Client{
+		conn:       framed,
+		to:         to,
+		localHost:  localHost,
+		localPort:  localPort,
+		remotePort: remotePort,
+		auth:       auth,
+		mode:       mode,
+		transport:  transport,
+		metrics:    newMetrics(),
+	}
+	client.connected.Store(true)
+	return client, nil
+}
+
+// NewClientWithMultiplexing connects to the remote server and performs the
+// initial handshake, then keeps that single underlying connection open for
+// the lifetime of the Client: every accepted connection is proxied over a
+// logical stream instead of dialing the server anew, which avoids paying a
+// fresh TCP (and TLS) handshake per proxied connection.
+func NewClientWithMultiplexing(localHost string, localPort uint16, to string, desiredPort uint16, secret string) (*Client, error) {
+	conn, err := dialServer(TCPTransport{}, to, ControlPort)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		to:        to,
+		localHost: localHost,
+		localPort: localPort,
+		mode:      ClientModeTCP,
+		transport: TCPTransport{},
+		metrics:   newMetrics(),
+	}
+	session := newMuxSession(conn, func() { client.lastHeartbeat.Store(time.Now().UnixNano()) })
+	framed := NewDelimited(session.controlStream())
+
+	var auth *Authenticator
+	if secret != "" {
+		auth = NewAuthenticator(secret)
+	}
+	remotePort, err := performHandshake(framed, desiredPort, auth, ClientModeTCP)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	client.conn = framed
+	client.remotePort = remotePort
+	client.auth = auth
+	client.muxSession = session
+	client.connected.Store(true)
+	return client, nil
+}
+
+// performHandshake runs the optional HMAC challenge/response (when auth is
+// non-nil) followed by the Hello/ServerHello exchange over framed, returning
+// the negotiated remote port. Reconnects reuse the Client's existing auth
+// rather than deriving a new Authenticator from the secret.
+func performHandshake(framed *Delimited, desiredPort uint16, auth *Authenticator, mode ClientMode) (uint16, error) {
+	if auth != nil {
 		if err := auth.ClientHandshake(framed); err != nil {
-			conn.Close()
-			return nil, err
+			return 0, err
 		}
 	}
 
-	if err := framed.SendJSON(encodeHello(desiredPort)); err != nil {
-		conn.Close()
-		return nil, err
+	hello := encodeHello(desiredPort)
+	if mode == ClientModeUDP {
+		hello = encodeHelloUDP(desiredPort)
+	}
+	if err := framed.SendJSON(hello); err != nil {
+		return 0, err
 	}
 
 	msg, ok, err := framed.RecvServer(true)
 	if err != nil {
-		conn.Close()
-		return nil, err
+		return 0, err
 	}
 	if !ok {
-		conn.Close()
-		return nil, ErrUnexpectedEOF
+		return 0, ErrUnexpectedEOF
 	}
 
 	switch msg.Kind {
 	case ServerHello:
 		log.Printf("connected to server, remote port %d", msg.Port)
 	case ServerError:
-		conn.Close()
-		return nil, fmt.Errorf("server error: %s", msg.ErrorText)
+		return 0, fmt.Errorf("server error: %s", msg.ErrorText)
 	case ServerChallenge:
-		conn.Close()
-		return nil, fmt.Errorf("server requires authentication but no secret was provided")
+		return 0, fmt.Errorf("server requires authentication but no secret was provided")
 	default:
-		conn.Close()
-		return nil, fmt.Errorf("unexpected initial message: %v", msg.Kind)
+		return 0, fmt.Errorf("unexpected initial message: %v", msg.Kind)
 	}
 
-	client := &Client{
-		conn:       framed,
-		to:         to,
-		localHost:  localHost,
-		localPort:  localPort,
-		remotePort: msg.Port,
-		auth:       auth,
-	}
-	client.connected.Store(true)
-	return client, nil
+	return msg.Port, nil
 }
 
 // RemotePort returns the public port assigned by the server.
 func (c *Client) RemotePort() uint16 {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
 	return c.remotePort
 }
 
+// setRemotePort records the port assigned by the most recent handshake.
+func (c *Client) setRemotePort(port uint16) {
+	c.connMu.Lock()
+	c.remotePort = port
+	c.connMu.Unlock()
+}
+
+// getConn returns the current control connection.
+func (c *Client) getConn() *Delimited {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.conn
+}
+
+// getMuxSession returns the current mux session, or nil if the Client isn't multiplexing.
+func (c *Client) getMuxSession() *muxSession {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.muxSession
+}
+
+// setConn installs the connection (and mux session, if multiplexing) that
+// Run/reconnect just established.
+func (c *Client) setConn(conn *Delimited, session *muxSession) {
+	c.connMu.Lock()
+	c.conn = conn
+	c.muxSession = session
+	c.connMu.Unlock()
+}
+
 // Connected reports whether the control connection is currently active.
 func (c *Client) Connected() bool {
 	return c.connected.Load()
@@ -110,18 +251,35 @@ func (c *Client) LastHeartbeat() (time.Time, bool) {
 	return time.Unix(0, ns), true
 }
 
-// Close shuts down the control connection.
+// Close shuts down the control connection. It is safe to call more than once.
 func (c *Client) Close() error {
 	var err error
 	c.closeOnce.Do(func() {
 		c.connected.Store(false)
-		if c.conn != nil {
-			err = c.conn.Close()
-		}
+		err = c.closeConn()
 	})
 	return err
 }
 
+// closeConn closes the current control connection (and mux session, if any)
+// without the terminal, once-only semantics of Close, so Run can tear down a
+// failed connection and later establish a fresh one.
+func (c *Client) closeConn() error {
+	c.connMu.RLock()
+	conn := c.conn
+	session := c.muxSession
+	c.connMu.RUnlock()
+
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+	if session != nil {
+		_ = session.Close()
+	}
+	return err
+}
+
 // Listen waits for new connection notifications from the server and proxies them.
 func (c *Client) Listen(ctx context.Context) error {
 	if ctx == nil {
@@ -137,8 +295,16 @@ func (c *Client) Listen(ctx context.Context) error {
 	}()
 
 	c.connected.Store(true)
+	return c.listenOnce()
+}
+
+// listenOnce runs the control-message receive loop until the connection fails
+// or the server closes it cleanly. Unlike Listen, it does not manage the
+// connected flag or tear down the connection, so Run can use it across
+// repeated reconnects.
+func (c *Client) listenOnce() error {
 	for {
-		msg, ok, err := c.conn.RecvServer(false)
+		msg, ok, err := c.getConn().RecvServer(false)
 		if err != nil {
 			return err
 		}
@@ -151,8 +317,20 @@ func (c *Client) Listen(ctx context.Context) error {
 			c.lastHeartbeat.Store(time.Now().UnixNano())
 		case ServerConnection:
 			id := msg.ID
+			sourceAddr := msg.SourceAddr
+			session := c.getMuxSession()
+			c.metrics.acceptedConnections.Add(1)
 			go func() {
-				if err := c.handleConnection(id); err != nil {
+				var err error
+				switch {
+				case session != nil:
+					err = c.handleConnectionMuxed(session, id, sourceAddr)
+				case c.mode == ClientModeUDP:
+					err = c.handleUDPConnection(id)
+				default:
+					err = c.handleConnection(id, sourceAddr)
+				}
+				if err != nil {
 					log.Printf("proxy for %s ended with error: %v", id, err)
 				}
 			}()
@@ -166,17 +344,18 @@ func (c *Client) Listen(ctx context.Context) error {
 	}
 }
 
-func (c *Client) handleConnection(id uuid.UUID) error {
+func (c *Client) handleConnection(id uuid.UUID, sourceAddr *net.TCPAddr) error {
 	c.activeProxies.Add(1)
 	defer c.activeProxies.Add(-1)
 
-	remoteConn, err := connectWithTimeout(c.to, ControlPort)
+	remoteConn, err := dialServer(c.transport, c.to, ControlPort)
 	if err != nil {
 		return fmt.Errorf("connect to server for proxy: %w", err)
 	}
 	framed := NewDelimited(remoteConn)
 	if c.auth != nil {
 		if err := c.auth.ClientHandshake(framed); err != nil {
+			c.metrics.handshakeFailures.Add(1)
 			framed.Close()
 			return err
 		}
@@ -201,6 +380,78 @@ func (c *Client) handleConnection(id uuid.UUID) error {
 		return fmt.Errorf("connect to local service: %w", err)
 	}
 
+	if err := c.writeProxyProtocolHeader(localConn, sourceAddr); err != nil {
+		localConn.Close()
+		framed.Close()
+		return err
+	}
+
+	if len(buffered) > 0 {
+		if _, err := localConn.Write(buffered); err != nil {
+			localConn.Close()
+			framed.Close()
+			return fmt.Errorf("write buffered data to local: %w", err)
+		}
+	}
+
+	return proxy(localConn, framed.RawConn(), c.metrics)
+}
+
+// writeProxyProtocolHeader, when PreserveSourceAddr is enabled and the server
+// reported the original client address, prepends a PROXY protocol v2 header
+// to localConn describing that address.
+func (c *Client) writeProxyProtocolHeader(localConn net.Conn, sourceAddr *net.TCPAddr) error {
+	if !c.PreserveSourceAddr || sourceAddr == nil {
+		return nil
+	}
+	dstAddr, ok := localConn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return nil
+	}
+	header, err := encodeProxyProtocolV2(sourceAddr, dstAddr)
+	if err != nil {
+		return fmt.Errorf("build PROXY protocol header: %w", err)
+	}
+	if _, err := localConn.Write(header); err != nil {
+		return fmt.Errorf("write PROXY protocol header: %w", err)
+	}
+	return nil
+}
+
+// handleConnectionMuxed proxies an accepted connection over a new logical
+// stream on the shared mux session instead of dialing the server anew.
+func (c *Client) handleConnectionMuxed(session *muxSession, id uuid.UUID, sourceAddr *net.TCPAddr) error {
+	c.activeProxies.Add(1)
+	defer c.activeProxies.Add(-1)
+
+	stream, err := session.OpenStream()
+	if err != nil {
+		return fmt.Errorf("open mux stream: %w", err)
+	}
+	framed := NewDelimited(stream)
+	if err := framed.SendJSON(encodeAccept(id)); err != nil {
+		framed.Close()
+		return err
+	}
+
+	buffered, err := framed.BufferedData()
+	if err != nil {
+		framed.Close()
+		return err
+	}
+
+	localConn, err := connectWithTimeout(c.localHost, c.localPort)
+	if err != nil {
+		framed.Close()
+		return fmt.Errorf("connect to local service: %w", err)
+	}
+
+	if err := c.writeProxyProtocolHeader(localConn, sourceAddr); err != nil {
+		localConn.Close()
+		framed.Close()
+		return err
+	}
+
 	if len(buffered) > 0 {
 		if _, err := localConn.Write(buffered); err != nil {
 			localConn.Close()
@@ -209,7 +460,7 @@ func (c *Client) handleConnection(id uuid.UUID) error {
 		}
 	}
 
-	return proxy(localConn, framed.RawConn())
+	return proxy(localConn, framed.RawConn(), c.metrics)
 }
 
 func connectWithTimeout(host string, port uint16) (net.Conn, error) {
@@ -8,7 +8,10 @@ import (
 )
 
 func main() {
-	client, err := borego.NewClient("localhost", 8000, "0.0.0.0", 0, "")
+	client, err := borego.NewClientWithTransport(
+		"localhost", 8000, "0.0.0.0", 0, "",
+		borego.WebSocketTransport{Path: "/bore"},
+	)
 	if err != nil {
 		log.Fatalf("failed to start client: %v", err)
 	}
@@ -0,0 +1,73 @@
+package borego
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics accumulates counters for a Client's lifetime: accepted
+// connections, bytes proxied in each direction, and handshake failures.
+// Active proxy count and heartbeat age are read live from the Client itself.
+type Metrics struct {
+	acceptedConnections atomic.Int64
+	handshakeFailures   atomic.Int64
+	bytesIn             atomic.Int64
+	bytesOut            atomic.Int64
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// countingWriter wraps an io.Writer, tallying every successful byte written
+// into counter. It's used to instrument proxy's two io.Copy directions.
+type countingWriter struct {
+	io.Writer
+	counter *atomic.Int64
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.counter.Add(int64(n))
+	return n, err
+}
+
+// MetricsHandler renders the Client's metrics in Prometheus text exposition
+// format, so a bore-go client can be scraped like any other Go service.
+func (c *Client) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		heartbeatAge := "NaN"
+		if ts, ok := c.LastHeartbeat(); ok {
+			heartbeatAge = fmt.Sprintf("%f", time.Since(ts).Seconds())
+		}
+
+		fmt.Fprintf(w, "# HELP bore_accepted_connections_total Connections accepted from the server.\n")
+		fmt.Fprintf(w, "# TYPE bore_accepted_connections_total counter\n")
+		fmt.Fprintf(w, "bore_accepted_connections_total %d\n", c.metrics.acceptedConnections.Load())
+
+		fmt.Fprintf(w, "# HELP bore_handshake_failures_total Per-connection handshake failures.\n")
+		fmt.Fprintf(w, "# TYPE bore_handshake_failures_total counter\n")
+		fmt.Fprintf(w, "bore_handshake_failures_total %d\n", c.metrics.handshakeFailures.Load())
+
+		fmt.Fprintf(w, "# HELP bore_bytes_in_total Bytes proxied from the server to the local service.\n")
+		fmt.Fprintf(w, "# TYPE bore_bytes_in_total counter\n")
+		fmt.Fprintf(w, "bore_bytes_in_total %d\n", c.metrics.bytesIn.Load())
+
+		fmt.Fprintf(w, "# HELP bore_bytes_out_total Bytes proxied from the local service to the server.\n")
+		fmt.Fprintf(w, "# TYPE bore_bytes_out_total counter\n")
+		fmt.Fprintf(w, "bore_bytes_out_total %d\n", c.metrics.bytesOut.Load())
+
+		fmt.Fprintf(w, "# HELP bore_active_proxies Currently active proxy connections.\n")
+		fmt.Fprintf(w, "# TYPE bore_active_proxies gauge\n")
+		fmt.Fprintf(w, "bore_active_proxies %d\n", c.ActiveProxies())
+
+		fmt.Fprintf(w, "# HELP bore_heartbeat_age_seconds Seconds since the last heartbeat was observed.\n")
+		fmt.Fprintf(w, "# TYPE bore_heartbeat_age_seconds gauge\n")
+		fmt.Fprintf(w, "bore_heartbeat_age_seconds %s\n", heartbeatAge)
+	})
+}
@@ -0,0 +1,356 @@
+package borego
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// muxFrameType identifies the kind of frame carried on a multiplexed session.
+type muxFrameType byte
+
+const (
+	muxSYN muxFrameType = iota
+	muxDATA
+	muxFIN
+	muxRST
+	muxWindowUpdate
+	muxPing
+)
+
+const (
+	// muxDefaultWindow is the per-stream flow-control window.
+	muxDefaultWindow = 256 * 1024
+	// muxMaxChunk caps how much of a Write is sent in a single DATA frame.
+	// MaxFrameLength does not apply here; it only bounds JSON sent through Delimited
+	// (the control channel on stream 0 and the Accept message opening each stream).
+	muxMaxChunk = 16 * 1024
+	// muxFrameHeaderLen is the fixed-size frame header: 4-byte stream ID, 1-byte type, 4-byte length.
+	muxFrameHeaderLen = 9
+)
+
+// muxSession multiplexes logical streams over a single underlying connection,
+// so a Client can proxy many connections without dialing the server anew for each one.
+type muxSession struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+
+	streamsMu sync.Mutex
+	streams   map[uint32]*muxStream
+	nextID    atomic.Uint32
+
+	// onPing is invoked whenever a ping frame arrives on the control stream.
+	onPing func()
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// newMuxSession wraps conn and starts demultiplexing incoming frames. Stream 0
+// is reserved for the Delimited control channel.
+func newMuxSession(conn net.Conn, onPing func()) *muxSession {
+	s := &muxSession{
+		conn:    conn,
+		streams: make(map[uint32]*muxStream),
+		onPing:  onPing,
+		closed:  make(chan struct{}),
+	}
+	s.streams[0] = newMuxStream(0, s)
+	go s.recvLoop()
+	return s
+}
+
+// controlStream returns the reserved stream 0, used to carry the Delimited control channel.
+func (s *muxSession) controlStream() *muxStream {
+	return s.streams[0]
+}
+
+// OpenStream allocates a new logical stream and announces it to the peer.
+func (s *muxSession) OpenStream() (*muxStream, error) {
+	id := s.nextID.Add(1)
+	stream := newMuxStream(id, s)
+
+	s.streamsMu.Lock()
+	s.streams[id] = stream
+	s.streamsMu.Unlock()
+
+	if err := s.writeFrame(id, muxSYN, nil); err != nil {
+		s.removeStream(id)
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (s *muxSession) writeFrame(id uint32, typ muxFrameType, payload []byte) error {
+	header := make([]byte, muxFrameHeaderLen)
+	binary.BigEndian.PutUint32(header[0:4], id)
+	header[4] = byte(typ)
+	binary.BigEndian.PutUint32(header[5:9], uint32(len(payload)))
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.conn.Write(header); err != nil {
+		return err
+	}
+	if len(payload) > 0 {
+		if _, err := s.conn.Write(payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeStream forcibly drops a stream and unblocks any pending Read/Write on
+// it; used for SYN-write failures and peer RST, where the stream is being
+// torn down rather than finishing a normal close.
+func (s *muxSession) removeStream(id uint32) {
+	s.streamsMu.Lock()
+	stream, ok := s.streams[id]
+	delete(s.streams, id)
+	s.streamsMu.Unlock()
+	if ok {
+		stream.closeLocal()
+	}
+}
+
+// dropStream removes a stream's entry once both directions have finished
+// normally (local FIN sent and remote FIN received). Stream 0, the control
+// stream, lives for the session's lifetime and is never dropped.
+func (s *muxSession) dropStream(id uint32) {
+	if id == 0 {
+		return
+	}
+	s.streamsMu.Lock()
+	delete(s.streams, id)
+	s.streamsMu.Unlock()
+}
+
+func (s *muxSession) recvLoop() {
+	defer s.Close()
+
+	header := make([]byte, muxFrameHeaderLen)
+	for {
+		if _, err := io.ReadFull(s.conn, header); err != nil {
+			return
+		}
+		id := binary.BigEndian.Uint32(header[0:4])
+		typ := muxFrameType(header[4])
+		length := binary.BigEndian.Uint32(header[5:9])
+
+		var payload []byte
+		if length > 0 {
+			payload = make([]byte, length)
+			if _, err := io.ReadFull(s.conn, payload); err != nil {
+				return
+			}
+		}
+
+		switch typ {
+		case muxPing:
+			if s.onPing != nil {
+				s.onPing()
+			}
+		case muxSYN:
+			s.streamsMu.Lock()
+			if _, exists := s.streams[id]; !exists {
+				s.streams[id] = newMuxStream(id, s)
+			}
+			s.streamsMu.Unlock()
+		case muxDATA:
+			if stream := s.stream(id); stream != nil {
+				stream.deliver(payload)
+			}
+		case muxWindowUpdate:
+			if len(payload) < 4 {
+				continue
+			}
+			if stream := s.stream(id); stream != nil {
+				stream.grantWindow(binary.BigEndian.Uint32(payload))
+			}
+		case muxFIN:
+			if stream := s.stream(id); stream != nil {
+				stream.closeRemote()
+			}
+		case muxRST:
+			s.removeStream(id)
+		}
+	}
+}
+
+func (s *muxSession) stream(id uint32) *muxStream {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	return s.streams[id]
+}
+
+// Close tears down the session and every stream opened on it.
+func (s *muxSession) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.conn.Close()
+		s.streamsMu.Lock()
+		for _, stream := range s.streams {
+			stream.closeLocal()
+		}
+		s.streamsMu.Unlock()
+	})
+	return nil
+}
+
+// muxStream is a logical, flow-controlled stream over a muxSession. It
+// satisfies net.Conn so it can be spliced with a local connection exactly
+// like the per-connection sockets handleConnection dials directly.
+type muxStream struct {
+	id      uint32
+	session *muxSession
+
+	recvCh  chan []byte
+	recvBuf []byte
+
+	sendWindow atomic.Int32
+	windowCh   chan struct{}
+
+	closeOnce     sync.Once
+	recvCloseOnce sync.Once
+	closed        chan struct{}
+
+	// localClosed and finReceived track each half of the stream so it can be
+	// dropped from the session's map once both are done; see maybeRemove.
+	localClosed atomic.Bool
+	finReceived atomic.Bool
+}
+
+func newMuxStream(id uint32, session *muxSession) *muxStream {
+	s := &muxStream{
+		id:       id,
+		session:  session,
+		recvCh:   make(chan []byte, 64),
+		windowCh: make(chan struct{}, 1),
+		closed:   make(chan struct{}),
+	}
+	s.sendWindow.Store(muxDefaultWindow)
+	return s
+}
+
+// deliver is called from the session's single recvLoop goroutine, so it never
+// races with closeRemote over recvCh. It drops the payload instead of
+// sending on recvCh once the peer's FIN has been observed, since recvCh is
+// closed at that point and a stray or duplicate DATA frame would otherwise
+// panic the whole session.
+func (s *muxStream) deliver(payload []byte) {
+	if s.finReceived.Load() {
+		return
+	}
+	select {
+	case s.recvCh <- payload:
+	case <-s.closed:
+	}
+}
+
+func (s *muxStream) grantWindow(delta uint32) {
+	s.sendWindow.Add(int32(delta))
+	select {
+	case s.windowCh <- struct{}{}:
+	default:
+	}
+}
+
+// closeRemote marks the peer as done sending, making Read return io.EOF once
+// buffered data is drained.
+func (s *muxStream) closeRemote() {
+	s.recvCloseOnce.Do(func() {
+		s.finReceived.Store(true)
+		close(s.recvCh)
+	})
+	s.maybeRemove()
+}
+
+// closeLocal unblocks any pending Read/Write without notifying the peer; used
+// when the whole session is torn down.
+func (s *muxStream) closeLocal() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+// maybeRemove drops the stream from the session once both directions have
+// closed, so a long-lived mux session doesn't accumulate one streams entry
+// per proxied connection for the life of the session.
+func (s *muxStream) maybeRemove() {
+	if s.localClosed.Load() && s.finReceived.Load() {
+		s.session.dropStream(s.id)
+	}
+}
+
+// Read implements net.Conn.
+func (s *muxStream) Read(p []byte) (int, error) {
+	for len(s.recvBuf) == 0 {
+		select {
+		case data, ok := <-s.recvCh:
+			if !ok {
+				return 0, io.EOF
+			}
+			s.recvBuf = data
+		case <-s.closed:
+			return 0, io.ErrClosedPipe
+		}
+	}
+	n := copy(p, s.recvBuf)
+	s.recvBuf = s.recvBuf[n:]
+	if n > 0 {
+		window := make([]byte, 4)
+		binary.BigEndian.PutUint32(window, uint32(n))
+		_ = s.session.writeFrame(s.id, muxWindowUpdate, window)
+	}
+	return n, nil
+}
+
+// Write implements net.Conn, splitting p into frames no larger than
+// muxMaxChunk and blocking on the peer's advertised window between them.
+func (s *muxStream) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > muxMaxChunk {
+			chunk = chunk[:muxMaxChunk]
+		}
+
+		for s.sendWindow.Load() <= 0 {
+			select {
+			case <-s.windowCh:
+			case <-s.closed:
+				return total, io.ErrClosedPipe
+			}
+		}
+
+		if err := s.session.writeFrame(s.id, muxDATA, chunk); err != nil {
+			return total, err
+		}
+		s.sendWindow.Add(-int32(len(chunk)))
+		total += len(chunk)
+		p = p[len(chunk):]
+	}
+	return total, nil
+}
+
+// Close implements net.Conn, notifying the peer with a FIN frame.
+func (s *muxStream) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		err = s.session.writeFrame(s.id, muxFIN, nil)
+		s.localClosed.Store(true)
+	})
+	s.maybeRemove()
+	return err
+}
+
+func (s *muxStream) LocalAddr() net.Addr  { return s.session.conn.LocalAddr() }
+func (s *muxStream) RemoteAddr() net.Addr { return s.session.conn.RemoteAddr() }
+
+// Deadlines are not supported on muxed streams; they are no-ops so muxStream
+// satisfies net.Conn for callers (like Delimited) that set deadlines unconditionally.
+func (s *muxStream) SetDeadline(t time.Time) error      { return nil }
+func (s *muxStream) SetReadDeadline(t time.Time) error  { return nil }
+func (s *muxStream) SetWriteDeadline(t time.Time) error { return nil }
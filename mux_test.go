@@ -0,0 +1,100 @@
+package borego
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMuxWriteFrameHeaderFormat(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+
+	session := &muxSession{conn: client}
+
+	done := make(chan []byte, 1)
+	go func() {
+		header := make([]byte, muxFrameHeaderLen)
+		if _, err := io.ReadFull(server, header); err != nil {
+			return
+		}
+		length := binary.BigEndian.Uint32(header[5:9])
+		payload := make([]byte, length)
+		io.ReadFull(server, payload) // drain so writeFrame's second Write doesn't block
+		done <- header
+	}()
+
+	payload := []byte("hello")
+	if err := session.writeFrame(42, muxDATA, payload); err != nil {
+		t.Fatalf("writeFrame: %v", err)
+	}
+
+	header := <-done
+	if len(header) != muxFrameHeaderLen {
+		t.Fatalf("header length = %d, want %d", len(header), muxFrameHeaderLen)
+	}
+	if id := binary.BigEndian.Uint32(header[0:4]); id != 42 {
+		t.Fatalf("stream id = %d, want 42", id)
+	}
+	if typ := muxFrameType(header[4]); typ != muxDATA {
+		t.Fatalf("frame type = %d, want muxDATA", typ)
+	}
+	if length := binary.BigEndian.Uint32(header[5:9]); length != uint32(len(payload)) {
+		t.Fatalf("length field = %d, want %d", length, len(payload))
+	}
+}
+
+func TestMuxStreamDropsFromSessionOnNormalClose(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	session := &muxSession{conn: client, streams: make(map[uint32]*muxStream)}
+	stream := newMuxStream(7, session)
+	session.streams[7] = stream
+
+	go func() {
+		// Drain the FIN frame writeFrame emits from Close so it doesn't block.
+		buf := make([]byte, muxFrameHeaderLen)
+		server.Read(buf)
+	}()
+
+	stream.closeRemote() // peer's half-close arrives first
+	if session.stream(7) == nil {
+		t.Fatal("stream removed before local Close(), want it to stay until both sides are done")
+	}
+
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if session.stream(7) != nil {
+		t.Fatal("stream still present in session.streams after both directions closed")
+	}
+}
+
+func TestMuxStreamDeliverAfterFinDoesNotPanic(t *testing.T) {
+	_, client := net.Pipe()
+	defer client.Close()
+
+	session := &muxSession{conn: client}
+	stream := newMuxStream(1, session)
+
+	stream.closeRemote()
+
+	// A stray or duplicate DATA frame after FIN must be dropped, not panic
+	// by sending on recvCh after it's been closed.
+	done := make(chan struct{})
+	go func() {
+		stream.deliver([]byte("late"))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("deliver did not return")
+	}
+}
@@ -139,6 +139,9 @@ type ServerMessage struct {
 	Port      uint16
 	ID        uuid.UUID
 	ErrorText string
+	// SourceAddr is the original client address for a ServerConnection
+	// message, when the server includes one. Nil if the server didn't send it.
+	SourceAddr *net.TCPAddr
 }
 
 func decodeServerMessage(data []byte) (ServerMessage, error) {
@@ -176,15 +179,31 @@ func decodeServerMessage(data []byte) (ServerMessage, error) {
 			}
 			return ServerMessage{Kind: ServerChallenge, ID: id}, nil
 		case "Connection":
-			var raw string
-			if err := json.Unmarshal(v, &raw); err != nil {
-				return ServerMessage{}, fmt.Errorf("invalid connection message: %w", err)
+			// Newer servers carry the original client address alongside the
+			// id so PreserveSourceAddr can emit a PROXY protocol v2 header;
+			// older servers may still send a bare UUID string.
+			var payload struct {
+				ID   string `json:"id"`
+				Addr string `json:"addr"`
 			}
-			id, err := uuid.Parse(raw)
+			if err := json.Unmarshal(v, &payload); err != nil || payload.ID == "" {
+				var raw string
+				if err := json.Unmarshal(v, &raw); err != nil {
+					return ServerMessage{}, fmt.Errorf("invalid connection message: %w", err)
+				}
+				payload.ID = raw
+			}
+			id, err := uuid.Parse(payload.ID)
 			if err != nil {
 				return ServerMessage{}, fmt.Errorf("invalid connection uuid: %w", err)
 			}
-			return ServerMessage{Kind: ServerConnection, ID: id}, nil
+			msg := ServerMessage{Kind: ServerConnection, ID: id}
+			if payload.Addr != "" {
+				if addr, err := net.ResolveTCPAddr("tcp", payload.Addr); err == nil {
+					msg.SourceAddr = addr
+				}
+			}
+			return msg, nil
 		case "Error":
 			var msg string
 			if err := json.Unmarshal(v, &msg); err != nil {
@@ -202,6 +221,11 @@ func encodeHello(port uint16) map[string]interface{} {
 	return map[string]interface{}{"Hello": port}
 }
 
+// encodeHelloUDP requests a UDP tunnel on the given desired port instead of a TCP one.
+func encodeHelloUDP(port uint16) map[string]interface{} {
+	return map[string]interface{}{"HelloUdp": port}
+}
+
 func encodeAuthenticate(tag string) map[string]interface{} {
 	return map[string]interface{}{"Authenticate": tag}
 }
@@ -5,24 +5,25 @@ import (
 	"net"
 )
 
-// proxy copies data between two network connections until either side closes.
-func proxy(a, b net.Conn) error {
+// proxy copies data between two network connections until either side
+// closes, recording bytes transferred in both directions on metrics.
+func proxy(local, remote net.Conn, metrics *Metrics) error {
 	errCh := make(chan error, 2)
 
 	go func() {
-		_, err := io.Copy(a, b)
+		_, err := io.Copy(countingWriter{local, &metrics.bytesIn}, remote)
 		errCh <- err
 	}()
 
 	go func() {
-		_, err := io.Copy(b, a)
+		_, err := io.Copy(countingWriter{remote, &metrics.bytesOut}, local)
 		errCh <- err
 	}()
 
 	err1 := <-errCh
 	// Close both sides to make sure the opposite goroutine exits.
-	_ = a.Close()
-	_ = b.Close()
+	_ = local.Close()
+	_ = remote.Close()
 	err2 := <-errCh
 
 	if err1 != nil && err1 != io.EOF {
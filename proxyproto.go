@@ -0,0 +1,55 @@
+package borego
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// proxyProtocolV2Signature is the fixed 12-byte signature that opens every
+// PROXY protocol v2 header.
+var proxyProtocolV2Signature = []byte{
+	0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A,
+}
+
+// encodeProxyProtocolV2 builds a PROXY protocol v2 header describing a TCP
+// connection from src to dst. Prefixing a stream with this header lets a
+// local service that understands PROXY v2 recover the original client
+// address instead of seeing the tunnel's own source address.
+func encodeProxyProtocolV2(src, dst *net.TCPAddr) ([]byte, error) {
+	srcIP4 := src.IP.To4()
+	dstIP4 := dst.IP.To4()
+
+	var famProto byte
+	var addrBlock []byte
+	if srcIP4 != nil && dstIP4 != nil {
+		famProto = 0x11 // AF_INET, STREAM
+		addrBlock = make([]byte, 12)
+		copy(addrBlock[0:4], srcIP4)
+		copy(addrBlock[4:8], dstIP4)
+		binary.BigEndian.PutUint16(addrBlock[8:10], uint16(src.Port))
+		binary.BigEndian.PutUint16(addrBlock[10:12], uint16(dst.Port))
+	} else {
+		srcIP6 := src.IP.To16()
+		dstIP6 := dst.IP.To16()
+		if srcIP6 == nil || dstIP6 == nil {
+			return nil, fmt.Errorf("invalid address for PROXY protocol header")
+		}
+		famProto = 0x21 // AF_INET6, STREAM
+		addrBlock = make([]byte, 36)
+		copy(addrBlock[0:16], srcIP6)
+		copy(addrBlock[16:32], dstIP6)
+		binary.BigEndian.PutUint16(addrBlock[32:34], uint16(src.Port))
+		binary.BigEndian.PutUint16(addrBlock[34:36], uint16(dst.Port))
+	}
+
+	header := make([]byte, 0, len(proxyProtocolV2Signature)+4+len(addrBlock))
+	header = append(header, proxyProtocolV2Signature...)
+	header = append(header, 0x21) // version 2, PROXY command
+	header = append(header, famProto)
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(addrBlock)))
+	header = append(header, length...)
+	header = append(header, addrBlock...)
+	return header, nil
+}
@@ -0,0 +1,73 @@
+package borego
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestEncodeProxyProtocolV2(t *testing.T) {
+	cases := []struct {
+		name    string
+		src     *net.TCPAddr
+		dst     *net.TCPAddr
+		wantFam byte
+		wantLen int
+	}{
+		{
+			name:    "ipv4",
+			src:     &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51820},
+			dst:     &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 8080},
+			wantFam: 0x11,
+			wantLen: 12,
+		},
+		{
+			name:    "ipv6",
+			src:     &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 51820},
+			dst:     &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 8080},
+			wantFam: 0x21,
+			wantLen: 36,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			header, err := encodeProxyProtocolV2(tc.src, tc.dst)
+			if err != nil {
+				t.Fatalf("encodeProxyProtocolV2: %v", err)
+			}
+
+			if !bytes.Equal(header[:12], proxyProtocolV2Signature) {
+				t.Fatalf("unexpected signature: %x", header[:12])
+			}
+			if header[12] != 0x21 {
+				t.Fatalf("version/command byte = %#x, want 0x21", header[12])
+			}
+			if header[13] != tc.wantFam {
+				t.Fatalf("family/proto byte = %#x, want %#x", header[13], tc.wantFam)
+			}
+			length := int(header[14])<<8 | int(header[15])
+			if length != tc.wantLen {
+				t.Fatalf("address block length = %d, want %d", length, tc.wantLen)
+			}
+			if len(header) != 16+tc.wantLen {
+				t.Fatalf("header length = %d, want %d", len(header), 16+tc.wantLen)
+			}
+		})
+	}
+}
+
+func TestEncodeProxyProtocolV2MixedFamilies(t *testing.T) {
+	// A v4 source paired with a v6-only destination must fall back to the v6
+	// address block rather than silently truncating either address.
+	src := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 51820}
+	dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 8080}
+
+	header, err := encodeProxyProtocolV2(src, dst)
+	if err != nil {
+		t.Fatalf("encodeProxyProtocolV2: %v", err)
+	}
+	if header[13] != 0x21 {
+		t.Fatalf("family/proto byte = %#x, want 0x21 (v6)", header[13])
+	}
+}
@@ -0,0 +1,133 @@
+package borego
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	// reconnectBackoffMin is the initial backoff before the first reconnect
+	// attempt; the actual wait is a random duration below it (see fullJitter).
+	reconnectBackoffMin = 500 * time.Millisecond
+	// reconnectBackoffMax caps the exponential backoff between reconnect attempts.
+	reconnectBackoffMax = 30 * time.Second
+)
+
+// Run keeps the Client connected for as long as ctx is alive: whenever the
+// control channel fails, it re-dials the server, replays Hello with the
+// previously assigned remote port to try to keep it, and resumes listening.
+// Reconnect attempts use exponential backoff with full jitter, starting at
+// reconnectBackoffMin and capped at reconnectBackoffMax. activeProxies and
+// other Client state carry over across reconnects since the Client itself is
+// reused; only the underlying connection is replaced.
+func (c *Client) Run(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	backoff := reconnectBackoffMin
+	for {
+		c.connected.Store(true)
+		if c.OnConnect != nil {
+			c.OnConnect()
+		}
+
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				_ = c.closeConn()
+			case <-done:
+			}
+		}()
+		err := c.listenOnce()
+		close(done)
+		c.connected.Store(false)
+
+		if ctx.Err() != nil {
+			_ = c.Close()
+			return ctx.Err()
+		}
+		if err == nil {
+			err = ErrUnexpectedEOF
+		}
+		_ = c.closeConn()
+		if c.OnDisconnect != nil {
+			c.OnDisconnect(err)
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				_ = c.Close()
+				return ctx.Err()
+			case <-time.After(fullJitter(backoff)):
+			}
+
+			oldPort := c.RemotePort()
+			newPort, dialErr := c.reconnect()
+			if dialErr != nil {
+				backoff = nextBackoff(backoff)
+				continue
+			}
+
+			c.setRemotePort(newPort)
+			if newPort != oldPort && c.OnRemotePortChanged != nil {
+				c.OnRemotePortChanged(oldPort, newPort)
+			}
+			backoff = reconnectBackoffMin
+			break
+		}
+	}
+}
+
+// reconnect re-dials the server over the Client's transport, replaying Hello
+// with the previously assigned remote port and redoing the HMAC handshake
+// (if auth is configured), then installs the new connection on the Client.
+func (c *Client) reconnect() (uint16, error) {
+	conn, err := dialServer(c.transport, c.to, ControlPort)
+	if err != nil {
+		return 0, err
+	}
+
+	desiredPort := c.RemotePort()
+
+	if c.getMuxSession() != nil {
+		session := newMuxSession(conn, func() { c.lastHeartbeat.Store(time.Now().UnixNano()) })
+		framed := NewDelimited(session.controlStream())
+		remotePort, err := performHandshake(framed, desiredPort, c.auth, c.mode)
+		if err != nil {
+			session.Close()
+			return 0, err
+		}
+		c.setConn(framed, session)
+		return remotePort, nil
+	}
+
+	framed := NewDelimited(conn)
+	remotePort, err := performHandshake(framed, desiredPort, c.auth, c.mode)
+	if err != nil {
+		conn.Close()
+		return 0, err
+	}
+	c.setConn(framed, nil)
+	return remotePort, nil
+}
+
+// nextBackoff doubles d, capped at reconnectBackoffMax.
+func nextBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > reconnectBackoffMax {
+		d = reconnectBackoffMax
+	}
+	return d
+}
+
+// fullJitter picks a random delay in [0, d), per the "full jitter" strategy.
+func fullJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
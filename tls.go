@@ -0,0 +1,81 @@
+package borego
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+)
+
+// Dialer configures (mutual) TLS for the control channel and every
+// per-connection proxy socket dialed back to the server.
+type Dialer struct {
+	// RootCAs verifies the server's certificate; nil uses the system pool.
+	RootCAs *x509.CertPool
+	// Certificates, when set, are presented to the server for mutual TLS.
+	Certificates []tls.Certificate
+	// ServerName overrides the SNI/verification name; defaults to the dialed host.
+	ServerName string
+	// InsecureSkipVerify disables server certificate verification. Do not use in production.
+	InsecureSkipVerify bool
+}
+
+func (d Dialer) config() *tls.Config {
+	return &tls.Config{
+		RootCAs:            d.RootCAs,
+		Certificates:       d.Certificates,
+		ServerName:         d.ServerName,
+		InsecureSkipVerify: d.InsecureSkipVerify,
+	}
+}
+
+// TLSTransport wraps another Transport, upgrading every dialed connection to
+// TLS once the underlying connection is established. The HMAC challenge/response
+// handshake (see Authenticator) still runs, but inside the resulting TLS session.
+type TLSTransport struct {
+	// Inner is the Transport used to establish the underlying connection;
+	// nil uses TCPTransport.
+	Inner Transport
+	// Config is the TLS configuration used for the handshake.
+	Config *tls.Config
+}
+
+// Dial implements Transport.
+func (t TLSTransport) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	inner := t.Inner
+	if inner == nil {
+		inner = TCPTransport{}
+	}
+	conn, err := inner.Dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := t.Config
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName == "" {
+		if host, _, splitErr := net.SplitHostPort(addr); splitErr == nil {
+			cfg = cfg.Clone()
+			cfg.ServerName = host
+		}
+	}
+
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("tls handshake with %s: %w", addr, err)
+	}
+	return tlsConn, nil
+}
+
+// NewClientWithTLS connects to the remote server over TLS, performing mutual
+// TLS when dialer.Certificates is set. If the server authenticates the client
+// via its certificate, pass secret="" to skip the HMAC challenge/response
+// entirely and rely on the TLS handshake alone.
+func NewClientWithTLS(localHost string, localPort uint16, to string, desiredPort uint16, secret string, dialer Dialer) (*Client, error) {
+	transport := TLSTransport{Inner: TCPTransport{}, Config: dialer.config()}
+	return newClient(localHost, localPort, to, desiredPort, secret, ClientModeTCP, transport)
+}
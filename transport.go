@@ -0,0 +1,36 @@
+package borego
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// Transport establishes the connection a Client uses to reach the bore
+// server, so the control channel (and the per-connection sockets dialed
+// from handleConnection) can run over carriers other than raw TCP.
+type Transport interface {
+	// Dial connects to addr (host:port), honoring ctx for cancellation/timeout.
+	Dial(ctx context.Context, addr string) (net.Conn, error)
+}
+
+// TCPTransport is the default Transport and dials plain TCP connections.
+type TCPTransport struct{}
+
+// Dial implements Transport.
+func (TCPTransport) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// dialServer connects to host:port over transport, applying NetworkTimeout.
+func dialServer(transport Transport, host string, port uint16) (net.Conn, error) {
+	addr := net.JoinHostPort(host, fmt.Sprint(port))
+	ctx, cancel := context.WithTimeout(context.Background(), NetworkTimeout)
+	defer cancel()
+	conn, err := transport.Dial(ctx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,202 @@
+package borego
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UDPIdleTimeout is how long a UDP tunnel may sit without traffic in either
+// direction before it is torn down. UDP has no FIN, so this is the only
+// signal we have that a session is done.
+const UDPIdleTimeout = 60 * time.Second
+
+// writeDatagramFrame length-prefixes payload with a 2-byte big-endian length
+// and writes it to w.
+func writeDatagramFrame(w io.Writer, payload []byte) error {
+	if len(payload) > 0xFFFF {
+		return fmt.Errorf("datagram too large: %d bytes", len(payload))
+	}
+	header := make([]byte, 2)
+	binary.BigEndian.PutUint16(header, uint16(len(payload)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readDatagramFrame reads a single length-prefixed datagram frame from r.
+func readDatagramFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint16(header)
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// handleUDPConnection accepts a server-announced connection in UDP mode: it
+// dials the local UDP service and the server back on a fresh control
+// connection, then shuttles datagrams between them as length-prefixed
+// frames until the tunnel goes idle.
+func (c *Client) handleUDPConnection(id uuid.UUID) error {
+	c.activeProxies.Add(1)
+	defer c.activeProxies.Add(-1)
+
+	remoteConn, err := dialServer(c.transport, c.to, ControlPort)
+	if err != nil {
+		return fmt.Errorf("connect to server for proxy: %w", err)
+	}
+	framed := NewDelimited(remoteConn)
+	if c.auth != nil {
+		if err := c.auth.ClientHandshake(framed); err != nil {
+			c.metrics.handshakeFailures.Add(1)
+			framed.Close()
+			return err
+		}
+	}
+	if err := framed.SendJSON(encodeAccept(id)); err != nil {
+		framed.Close()
+		return err
+	}
+
+	// Clear deadlines before handing the socket to the datagram loops below.
+	_ = remoteConn.SetDeadline(time.Time{})
+
+	buffered, err := framed.BufferedData()
+	if err != nil {
+		framed.Close()
+		return err
+	}
+
+	localAddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(c.localHost, fmt.Sprint(c.localPort)))
+	if err != nil {
+		framed.Close()
+		return fmt.Errorf("resolve local UDP addr: %w", err)
+	}
+	localConn, err := net.DialUDP("udp", nil, localAddr)
+	if err != nil {
+		framed.Close()
+		return fmt.Errorf("dial local UDP service: %w", err)
+	}
+
+	tunnel := framed.RawConn()
+	if len(buffered) > 0 {
+		// The bufio.Reader behind the handshake may have over-read the start
+		// of the datagram-framed stream; replay it before reading live.
+		tunnel = &prefixedConn{Conn: tunnel, buf: buffered}
+	}
+
+	return proxyUDP(localConn, tunnel, c.metrics)
+}
+
+// prefixedConn serves buffered bytes on the first Read(s) before falling
+// through to the wrapped connection, so data consumed by a bufio.Reader
+// during an earlier framing step isn't lost when the raw conn is reused.
+type prefixedConn struct {
+	net.Conn
+	buf []byte
+}
+
+func (c *prefixedConn) Read(p []byte) (int, error) {
+	if len(c.buf) > 0 {
+		n := copy(p, c.buf)
+		c.buf = c.buf[n:]
+		return n, nil
+	}
+	return c.Conn.Read(p)
+}
+
+// proxyUDP shuttles datagrams between a local UDP socket and a length-framed
+// tunnel connection until either side errors, closes, or the pair goes idle
+// for UDPIdleTimeout, recording bytes transferred in both directions on metrics.
+func proxyUDP(local *net.UDPConn, tunnel net.Conn, metrics *Metrics) error {
+	var lastActivity atomic.Int64
+	lastActivity.Store(time.Now().UnixNano())
+
+	done := make(chan struct{})
+	errCh := make(chan error, 2)
+
+	closeBoth := func() {
+		local.Close()
+		tunnel.Close()
+	}
+
+	// Watchdog: close both sides once the tunnel has been idle too long.
+	go func() {
+		ticker := time.NewTicker(UDPIdleTimeout / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				idleFor := time.Since(time.Unix(0, lastActivity.Load()))
+				if idleFor >= UDPIdleTimeout {
+					closeBoth()
+					return
+				}
+			}
+		}
+	}()
+
+	// local UDP socket -> tunnel
+	go func() {
+		buf := make([]byte, 65507)
+		for {
+			n, err := local.Read(buf)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			lastActivity.Store(time.Now().UnixNano())
+			if err := writeDatagramFrame(tunnel, buf[:n]); err != nil {
+				errCh <- err
+				return
+			}
+			metrics.bytesOut.Add(int64(n))
+		}
+	}()
+
+	// tunnel -> local UDP socket
+	go func() {
+		for {
+			payload, err := readDatagramFrame(tunnel)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			lastActivity.Store(time.Now().UnixNano())
+			if _, err := local.Write(payload); err != nil {
+				errCh <- err
+				return
+			}
+			metrics.bytesIn.Add(int64(len(payload)))
+		}
+	}()
+
+	err1 := <-errCh
+	close(done)
+	closeBoth()
+	err2 := <-errCh
+
+	if err1 != nil && err1 != io.EOF {
+		return err1
+	}
+	if err2 != nil && err2 != io.EOF {
+		return err2
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package borego
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestDatagramFrameRoundTrip(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload []byte
+	}{
+		{name: "empty", payload: []byte{}},
+		{name: "short", payload: []byte("hello")},
+		{name: "max", payload: bytes.Repeat([]byte{0xAB}, 0xFFFF)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeDatagramFrame(&buf, tc.payload); err != nil {
+				t.Fatalf("writeDatagramFrame: %v", err)
+			}
+
+			got, err := readDatagramFrame(&buf)
+			if err != nil {
+				t.Fatalf("readDatagramFrame: %v", err)
+			}
+			if !bytes.Equal(got, tc.payload) {
+				t.Fatalf("round-tripped payload = %x, want %x", got, tc.payload)
+			}
+		})
+	}
+}
+
+func TestDatagramFrameTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeDatagramFrame(&buf, make([]byte, 0x10000))
+	if err == nil {
+		t.Fatal("expected an error for an oversized datagram, got nil")
+	}
+}
+
+func TestDatagramFrameMultiple(t *testing.T) {
+	var buf bytes.Buffer
+	want := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+	for _, p := range want {
+		if err := writeDatagramFrame(&buf, p); err != nil {
+			t.Fatalf("writeDatagramFrame: %v", err)
+		}
+	}
+
+	for _, w := range want {
+		got, err := readDatagramFrame(&buf)
+		if err != nil {
+			t.Fatalf("readDatagramFrame: %v", err)
+		}
+		if !bytes.Equal(got, w) {
+			t.Fatalf("readDatagramFrame = %q, want %q", got, w)
+		}
+	}
+
+	if _, err := readDatagramFrame(&buf); err != io.EOF {
+		t.Fatalf("readDatagramFrame at end = %v, want io.EOF", err)
+	}
+}
+
+func TestPrefixedConnReplaysBufferedBytesBeforeUnderlyingReads(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		server.Write([]byte("live"))
+		server.Close()
+	}()
+
+	pc := &prefixedConn{Conn: client, buf: []byte("buffered-")}
+
+	got := make([]byte, 64)
+	n, err := pc.Read(got)
+	if err != nil {
+		t.Fatalf("first Read: %v", err)
+	}
+	if string(got[:n]) != "buffered-" {
+		t.Fatalf("first Read = %q, want %q", got[:n], "buffered-")
+	}
+
+	n, err = io.ReadAtLeast(pc, got, 4)
+	if err != nil {
+		t.Fatalf("second Read: %v", err)
+	}
+	if string(got[:n]) != "live" {
+		t.Fatalf("second Read = %q, want %q", got[:n], "live")
+	}
+}
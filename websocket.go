@@ -0,0 +1,164 @@
+package borego
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketTransport dials the control channel and proxied connections as
+// WebSocket connections over HTTPS, so bore can traverse HTTP-only egress
+// and sit behind a plain nginx/Caddy front door.
+type WebSocketTransport struct {
+	// Path is the HTTP path the server's WebSocket upgrade is mounted on.
+	// Defaults to "/bore" when empty.
+	Path string
+
+	// TLSConfig configures the underlying TLS connection; nil uses Go's defaults.
+	TLSConfig *tls.Config
+
+	// Dialer overrides the gorilla/websocket dialer used to connect; nil uses
+	// websocket.DefaultDialer.
+	Dialer *websocket.Dialer
+}
+
+// Dial implements Transport by performing a WebSocket upgrade against addr.
+func (t WebSocketTransport) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	path := t.Path
+	if path == "" {
+		path = "/bore"
+	}
+	u := url.URL{Scheme: "wss", Host: addr, Path: path}
+
+	dialer := t.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	if t.TLSConfig != nil {
+		withTLS := *dialer
+		withTLS.TLSClientConfig = t.TLSConfig
+		dialer = &withTLS
+	}
+
+	ws, resp, err := dialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("websocket dial %s: %w", u.String(), err)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return newWSConn(ws), nil
+}
+
+// wsConn adapts a *websocket.Conn to the net.Conn interface, preserving the
+// null-delimited JSON framing used by Delimited inside binary WS messages:
+// every Write becomes one binary message, and reads reassemble messages into
+// a byte stream so bufio.Reader can consume them like any other socket.
+type wsConn struct {
+	ws      *websocket.Conn
+	readBuf bytes.Buffer
+}
+
+func newWSConn(ws *websocket.Conn) *wsConn {
+	return &wsConn{ws: ws}
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for c.readBuf.Len() == 0 {
+		msgType, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		if msgType != websocket.BinaryMessage {
+			continue
+		}
+		c.readBuf.Write(data)
+	}
+	return c.readBuf.Read(p)
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.ws.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) Close() error                       { return c.ws.Close() }
+func (c *wsConn) LocalAddr() net.Addr                { return c.ws.LocalAddr() }
+func (c *wsConn) RemoteAddr() net.Addr               { return c.ws.RemoteAddr() }
+func (c *wsConn) SetReadDeadline(t time.Time) error  { return c.ws.SetReadDeadline(t) }
+func (c *wsConn) SetWriteDeadline(t time.Time) error { return c.ws.SetWriteDeadline(t) }
+
+func (c *wsConn) SetDeadline(t time.Time) error {
+	if err := c.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.ws.SetWriteDeadline(t)
+}
+
+// WebSocketListener upgrades incoming HTTP requests to WebSocket connections
+// and exposes them through the net.Listener interface, so a bore server can
+// accept control and proxy connections fronted by an HTTP(S) reverse proxy.
+// Wire it up with http.Handle(path, listener) and pass it to http.Serve (or
+// an *http.Server), then Accept connections from it like any other listener.
+type WebSocketListener struct {
+	Upgrader websocket.Upgrader
+
+	addr   net.Addr
+	connCh chan net.Conn
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewWebSocketListener constructs a listener reporting addr from Addr().
+func NewWebSocketListener(addr net.Addr) *WebSocketListener {
+	return &WebSocketListener{
+		addr:   addr,
+		connCh: make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+// ServeHTTP upgrades the request and hands the resulting connection to Accept.
+func (l *WebSocketListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := l.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	select {
+	case l.connCh <- newWSConn(ws):
+	case <-l.closed:
+		ws.Close()
+	}
+}
+
+// Accept implements net.Listener.
+func (l *WebSocketListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.connCh:
+		return conn, nil
+	case <-l.closed:
+		return nil, fmt.Errorf("websocket listener closed")
+	}
+}
+
+// Close implements net.Listener.
+func (l *WebSocketListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+// Addr implements net.Listener.
+func (l *WebSocketListener) Addr() net.Addr {
+	return l.addr
+}